@@ -93,7 +93,13 @@ type Image struct {
 	bounds   image.Rectangle
 	original *Image
 
-	pendingPixels []byte
+	pendingPixels          []byte
+	pendingPixelsDirtyRect image.Rectangle
+
+	// pixelsCache is a snapshot of the image's pixels, lazily populated by
+	// Pixels and invalidated whenever the image's content changes. It is
+	// only ever set on an original image, never on a sub-image.
+	pixelsCache []byte
 
 	filter Filter
 }
@@ -154,22 +160,41 @@ func (i *Image) Fill(clr color.Color) error {
 		return nil
 	}
 
-	// TODO: Implement this.
-	if i.isSubImage() {
-		panic("ebiten: render to a subimage is not implemented (Fill)")
-	}
-
-	i.resolvePendingPixels(false)
-
-	i.mipmap.fill(clr)
+	// Fill on a sub-image only overwrites its own region, so any pending
+	// Set() writes to the rest of the original image must be flushed first
+	// instead of discarded: discarding (draw:false) is only safe when Fill
+	// is about to overwrite every pixel resolvePendingPixels(false) would drop.
+	i.resolvePendingPixels(i.isSubImage())
+	i.mipmap.fill(clr, i.regionForMipmap())
+	i.invalidatePixelsCache()
 	return nil
 }
 
+// regionForMipmap returns the region that this image occupies in the
+// coordinate space of its underlying mipmap, i.e. the coordinate space
+// shared by the original image and all of its sub-images.
+//
+// For a non-sub-image, this is always the whole image. For a sub-image,
+// this is i.bounds, which SubImage already expresses in those
+// coordinates. The driver uses this as a scissor
+// rectangle so that rendering into a sub-image never touches pixels
+// outside of it.
+func (i *Image) regionForMipmap() driver.Region {
+	b := i.Bounds()
+	return driver.Region{
+		X:      b.Min.X,
+		Y:      b.Min.Y,
+		Width:  b.Dx(),
+		Height: b.Dy(),
+	}
+}
+
 func (i *Image) disposeMipmaps() {
 	if i.isDisposed() {
 		panic("ebiten: the image is already disposed at disposeMipmap")
 	}
 	i.mipmap.disposeMipmaps()
+	i.invalidatePixelsCache()
 }
 
 // DrawImage draws the given image on the image i.
@@ -228,11 +253,6 @@ func (i *Image) DrawImage(img *Image, options *DrawImageOptions) error {
 		return nil
 	}
 
-	// TODO: Implement this.
-	if i.isSubImage() {
-		panic("ebiten: render to a subimage is not implemented (drawImage)")
-	}
-
 	img.resolvePendingPixels(true)
 	i.resolvePendingPixels(true)
 
@@ -335,12 +355,18 @@ func (i *Image) DrawImage(img *Image, options *DrawImageOptions) error {
 	}
 
 	a, b, c, d, tx, ty := geom.elements()
+	if i.isSubImage() {
+		// (0, 0) in a sub-image corresponds to bounds.Min in its original image.
+		tx += float32(i.bounds.Min.X)
+		ty += float32(i.bounds.Min.Y)
+	}
+	region := i.regionForMipmap()
 	if level == 0 {
 		src := img.mipmap.original()
 		vs := vertexSlice(4)
 		graphics.PutQuadVertices(vs, src, bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y, a, b, c, d, tx, ty, cr, cg, cb, ca)
 		is := graphics.QuadIndices()
-		i.mipmap.original().DrawTriangles(src, vs, is, colorm, mode, filter, driver.AddressClampToZero)
+		i.mipmap.original().DrawTriangles(src, vs, is, 4, colorm, mode, filter, driver.AddressClampToZero, region)
 	} else if src := img.mipmap.level(bounds, level); src != nil {
 		w, h := src.Size()
 		s := pow2(level)
@@ -351,7 +377,7 @@ func (i *Image) DrawImage(img *Image, options *DrawImageOptions) error {
 		vs := vertexSlice(4)
 		graphics.PutQuadVertices(vs, src, 0, 0, w, h, a, b, c, d, tx, ty, cr, cg, cb, ca)
 		is := graphics.QuadIndices()
-		i.mipmap.original().DrawTriangles(src, vs, is, colorm, mode, filter, driver.AddressClampToZero)
+		i.mipmap.original().DrawTriangles(src, vs, is, 4, colorm, mode, filter, driver.AddressClampToZero, region)
 	}
 	i.disposeMipmaps()
 	return nil
@@ -378,6 +404,16 @@ type Vertex struct {
 	ColorG float32
 	ColorB float32
 	ColorA float32
+
+	// Custom0/Custom1/Custom2/Custom3 are custom attributes that are only
+	// read when the vertices are used with DrawTrianglesWithShader. They
+	// are ignored by DrawTriangles.
+	//
+	// Note that this API is experimental.
+	Custom0 float32
+	Custom1 float32
+	Custom2 float32
+	Custom3 float32
 }
 
 // Address represents a sampler address mode.
@@ -449,10 +485,6 @@ func (i *Image) DrawTriangles(vertices []Vertex, indices []uint16, img *Image, o
 		return
 	}
 
-	if i.isSubImage() {
-		panic("ebiten: render to a subimage is not implemented (DrawTriangles)")
-	}
-
 	img.resolvePendingPixels(true)
 	i.resolvePendingPixels(true)
 
@@ -477,16 +509,22 @@ func (i *Image) DrawTriangles(vertices []Vertex, indices []uint16, img *Image, o
 		filter = driver.Filter(img.filter)
 	}
 
+	// (0, 0) in a sub-image corresponds to bounds.Min in its original image.
+	var dx, dy float32
+	if i.isSubImage() {
+		dx, dy = float32(i.bounds.Min.X), float32(i.bounds.Min.Y)
+	}
+
 	vs := vertexSlice(len(vertices))
 	src := img.mipmap.original()
 	r := img.Bounds()
 	for idx, v := range vertices {
 		src.PutVertex(vs[idx*graphics.VertexFloatNum:(idx+1)*graphics.VertexFloatNum],
-			float32(v.DstX), float32(v.DstY), v.SrcX, v.SrcY,
+			float32(v.DstX)+dx, float32(v.DstY)+dy, v.SrcX, v.SrcY,
 			float32(r.Min.X), float32(r.Min.Y), float32(r.Max.X), float32(r.Max.Y),
 			v.ColorR, v.ColorG, v.ColorB, v.ColorA)
 	}
-	i.mipmap.original().DrawTriangles(src, vs, indices, options.ColorM.impl, mode, filter, driver.Address(options.Address))
+	i.mipmap.original().DrawTriangles(src, vs, indices, len(vertices), options.ColorM.impl, mode, filter, driver.Address(options.Address), i.regionForMipmap())
 	i.disposeMipmaps()
 }
 
@@ -496,7 +534,9 @@ func (i *Image) DrawTriangles(vertices []Vertex, indices []uint16, img *Image, o
 //
 // If the image is disposed, SubImage returns nil.
 //
-// In the current Ebiten implementation, SubImage is available only as a rendering source.
+// A sub-image can be used both as a rendering source and as a rendering
+// destination (e.g. with Fill, DrawImage, DrawTriangles, or ReplacePixels).
+// Rendering into a sub-image only ever touches the pixels within its bounds.
 func (i *Image) SubImage(r image.Rectangle) image.Image {
 	i.copyCheck()
 	if i.isDisposed() {
@@ -543,6 +583,100 @@ func (i *Image) ColorModel() color.Model {
 	return color.RGBAModel
 }
 
+// Pixels returns a copy of the image's pixels, as alpha-premultiplied RGBA.
+//
+// Pixels is backed by a staging buffer that is lazily populated from the GPU
+// on the first call, and is invalidated whenever the image is drawn to (Fill,
+// DrawImage, DrawTriangles, DrawTrianglesWithShader) or ReplacePixels is
+// called. Calling Pixels repeatedly between such changes only stalls the GPU
+// once. At and Set read through the same buffer.
+//
+// Pixels always returns a nil error as of 1.13.0-alpha.
+//
+// Pixels can't be called outside the main loop (ebiten.Run's updating function) starts.
+func (i *Image) Pixels() ([]byte, error) {
+	i.copyCheck()
+	checkNeedsEnqueueImageOp("(*Image).Pixels")
+
+	if i.isDisposed() {
+		return nil, nil
+	}
+
+	if i.isSubImage() {
+		p, err := i.original.Pixels()
+		if err != nil {
+			return nil, err
+		}
+		ow, _ := i.original.Size()
+		sx0, sy0 := i.bounds.Min.X, i.bounds.Min.Y
+		sw, sh := i.bounds.Dx(), i.bounds.Dy()
+		pix := make([]byte, 4*sw*sh)
+		for y := 0; y < sh; y++ {
+			srcOff := 4 * ((sy0+y)*ow + sx0)
+			copy(pix[4*y*sw:4*(y+1)*sw], p[srcOff:srcOff+4*sw])
+		}
+		return pix, nil
+	}
+
+	i.ensurePixelsCache()
+
+	pix := make([]byte, len(i.pixelsCache))
+	copy(pix, i.pixelsCache)
+	return pix, nil
+}
+
+// ensurePixelsCache populates i.pixelsCache from the GPU if it isn't already
+// warm. i must not be a sub-image.
+func (i *Image) ensurePixelsCache() {
+	i.resolvePendingPixels(true)
+
+	if i.pixelsCache != nil {
+		return
+	}
+
+	w, h := i.Size()
+	pix := make([]byte, 4*w*h)
+	idx := 0
+	for j := 0; j < h; j++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := i.mipmap.at(x, j)
+			pix[4*idx] = r
+			pix[4*idx+1] = g
+			pix[4*idx+2] = b
+			pix[4*idx+3] = a
+			idx++
+		}
+	}
+	i.pixelsCache = pix
+}
+
+// pixelAt returns the RGBA value of a single pixel at (x, y) in the
+// original (non-sub-image) image's coordinates. Unlike Pixels, it never
+// allocates or copies a whole-image buffer for the caller: it reads
+// straight out of pixelsCache once that's warm, making repeated At calls
+// between invalidations O(1) instead of O(image size) each.
+func (i *Image) pixelAt(x, y int) (byte, byte, byte, byte) {
+	if i.isSubImage() {
+		return i.original.pixelAt(x, y)
+	}
+
+	i.ensurePixelsCache()
+
+	w, _ := i.Size()
+	idx := 4 * (y*w + x)
+	return i.pixelsCache[idx], i.pixelsCache[idx+1], i.pixelsCache[idx+2], i.pixelsCache[idx+3]
+}
+
+// invalidatePixelsCache discards the cached result of Pixels. It must be
+// called whenever this image's original's pixels change.
+func (i *Image) invalidatePixelsCache() {
+	if i.isSubImage() {
+		i.original.invalidatePixelsCache()
+		return
+	}
+	i.pixelsCache = nil
+}
+
 // At returns the color of the image at (x, y).
 //
 // At loads pixels from GPU to system memory if necessary, which means that At can be slow.
@@ -559,14 +693,49 @@ func (i *Image) At(x, y int) color.Color {
 	if i.isDisposed() {
 		return color.RGBA{}
 	}
-	if i.isSubImage() && !image.Pt(x, y).In(i.bounds) {
+	if !image.Pt(x, y).In(i.Bounds()) {
 		return color.RGBA{}
 	}
-	i.resolvePendingPixels(true)
-	r, g, b, a := i.mipmap.at(x, y)
+
+	r, g, b, a := i.pixelAt(x, y)
 	return color.RGBA{r, g, b, a}
 }
 
+// AtAsync returns a channel that receives the color of the image at (x, y).
+//
+// The readback goes through the graphicscommand layer, which reads the pixel
+// from its in-memory buffer and delivers it at once: the value is already in
+// the channel when AtAsync returns. No fenced GPU readback (glReadPixels into
+// a mapped buffer, or a Metal blit copy) is issued. The channel has a buffer
+// of 1 and is closed after the single value is sent, or closed without a
+// value if the image is disposed or (x, y) is out of bounds.
+//
+// AtAsync can't be called outside the main loop (ebiten.Run's updating function) starts.
+//
+// Note that this API is experimental.
+func (i *Image) AtAsync(x, y int) <-chan color.Color {
+	checkNeedsEnqueueImageOp("(*Image).AtAsync")
+
+	ch := make(chan color.Color, 1)
+
+	if i.isDisposed() {
+		close(ch)
+		return ch
+	}
+	if !image.Pt(x, y).In(i.Bounds()) {
+		close(ch)
+		return ch
+	}
+
+	i.resolvePendingPixels(true)
+
+	i.mipmap.atAsync(x, y, func(r, g, b, a byte) {
+		ch <- color.RGBA{r, g, b, a}
+		close(ch)
+	})
+	return ch
+}
+
 // Set sets the color at (x, y).
 //
 // Set loads pixels from GPU to system memory if necessary, which means that Set can be slow.
@@ -588,29 +757,39 @@ func (img *Image) Set(x, y int, clr color.Color) {
 		img = img.original
 	}
 
-	w, h := img.Size()
+	w, _ := img.Size()
 	if img.pendingPixels == nil {
-		pix := make([]byte, 4*w*h)
-		idx := 0
-		for j := 0; j < h; j++ {
-			for i := 0; i < w; i++ {
-				r, g, b, a := img.mipmap.at(i, j)
-				pix[4*idx] = r
-				pix[4*idx+1] = g
-				pix[4*idx+2] = b
-				pix[4*idx+3] = a
-				idx++
-			}
+		pix, err := img.Pixels()
+		if err != nil {
+			panic(fmt.Sprintf("ebiten: Pixels failed: %v", err))
 		}
 		img.pendingPixels = pix
+		img.pendingPixelsDirtyRect = image.ZR
 	}
 	r, g, b, a := clr.RGBA()
 	img.pendingPixels[4*(x+y*w)] = byte(r >> 8)
 	img.pendingPixels[4*(x+y*w)+1] = byte(g >> 8)
 	img.pendingPixels[4*(x+y*w)+2] = byte(b >> 8)
 	img.pendingPixels[4*(x+y*w)+3] = byte(a >> 8)
+
+	pt := image.Pt(x, y)
+	touched := image.Rectangle{Min: pt, Max: pt.Add(image.Pt(1, 1))}
+	if img.pendingPixelsDirtyRect.Empty() {
+		img.pendingPixelsDirtyRect = touched
+	} else {
+		img.pendingPixelsDirtyRect = img.pendingPixelsDirtyRect.Union(touched)
+	}
 }
 
+// resolvePendingPixels writes back any pixels queued by Set. Only the
+// rectangle that was actually touched since the staging buffer was
+// populated is sent to the driver, at that rectangle's own region, rather
+// than the whole image.
+//
+// The driver write goes straight through i.mipmap, not through the public
+// ReplacePixels, since ReplacePixels itself calls resolvePendingPixels: going
+// through it here would re-enter this very flush before pendingPixels is
+// cleared.
 func (i *Image) resolvePendingPixels(draw bool) {
 	if i.isSubImage() {
 		i.original.resolvePendingPixels(draw)
@@ -623,11 +802,23 @@ func (i *Image) resolvePendingPixels(draw bool) {
 
 	if !draw {
 		i.pendingPixels = nil
+		i.pendingPixelsDirtyRect = image.ZR
 		return
 	}
 
-	i.ReplacePixels(i.pendingPixels)
+	if r := i.pendingPixelsDirtyRect; !r.Empty() {
+		w, _ := i.Size()
+		sw, sh := r.Dx(), r.Dy()
+		pix := make([]byte, 4*sw*sh)
+		for y := 0; y < sh; y++ {
+			srcOff := 4 * ((r.Min.Y+y)*w + r.Min.X)
+			copy(pix[4*y*sw:4*(y+1)*sw], i.pendingPixels[srcOff:srcOff+4*sw])
+		}
+		i.mipmap.replacePixels(pix, driver.Region{X: r.Min.X, Y: r.Min.Y, Width: sw, Height: sh})
+		i.invalidatePixelsCache()
+	}
 	i.pendingPixels = nil
+	i.pendingPixelsDirtyRect = image.ZR
 }
 
 // Dispose disposes the image data. After disposing, most of image functions do nothing and returns meaningless values.
@@ -687,16 +878,20 @@ func (i *Image) ReplacePixels(p []byte) error {
 	if i.isDisposed() {
 		return nil
 	}
-	// TODO: Implement this.
-	if i.isSubImage() {
-		panic("ebiten: render to a subimage is not implemented (ReplacePixels)")
-	}
-	i.resolvePendingPixels(false)
+	// As with Fill, only flush (rather than discard) pending Set() writes
+	// when this is a sub-image, since ReplacePixels then only overwrites
+	// its own region and leaves the rest of the original image's pending
+	// writes outstanding.
+	i.resolvePendingPixels(i.isSubImage())
 	s := i.Bounds().Size()
 	if l := 4 * s.X * s.Y; len(p) != l {
 		panic(fmt.Sprintf("ebiten: len(p) was %d but must be %d", len(p), l))
 	}
-	i.mipmap.replacePixels(p)
+	// ReplacePixels on a sub-image only touches the pixels in its region: this
+	// translates to a glTexSubImage2D (or equivalent) at i.regionForMipmap's offset,
+	// leaving the rest of the original image untouched.
+	i.mipmap.replacePixels(p, i.regionForMipmap())
+	i.invalidatePixelsCache()
 	return nil
 }
 
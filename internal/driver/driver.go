@@ -0,0 +1,57 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package driver defines the types shared by every graphics backend
+// (OpenGL, Metal, ...). The backends themselves live in sibling packages
+// and are not part of this chunk.
+package driver
+
+// Region represents a rectangle in a destination image's pixel coordinates.
+//
+// Region is applied by the graphicscommand layer as a scissor rect (e.g.
+// glScissor on OpenGL, or the equivalent render-pass scissor on Metal) at
+// command-execution time, so that a draw or a pixel replacement never
+// touches a pixel outside of it.
+type Region struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Filter represents a type of texture filter to be used when an image is
+// scaled or rotated.
+type Filter int
+
+const (
+	FilterNearest Filter = iota
+	FilterLinear
+)
+
+// Address represents a sampler address mode.
+type Address int
+
+const (
+	AddressClampToZero Address = iota
+	AddressRepeat
+)
+
+// CompositeMode represents a composite (blending) mode.
+type CompositeMode int
+
+const (
+	CompositeModeSourceOver CompositeMode = iota
+	CompositeModeClear
+	CompositeModeCopy
+)
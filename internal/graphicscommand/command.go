@@ -0,0 +1,116 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphicscommand
+
+import (
+	"github.com/hajimehoshi/ebiten/internal/driver"
+)
+
+// drawTrianglesCommand represents a batched triangle-draw command against a
+// destination image.
+//
+// Two consecutive drawTrianglesCommands can be merged into one draw call
+// (fewer state changes) only when everything that affects how the GPU
+// renders them is identical, including the scissor rectangle: merging across
+// different regions would either draw outside the intended area or require
+// an extra scissor change that defeats the point of batching.
+type drawTrianglesCommand struct {
+	dst *Image
+	// srcs holds up to 4 source images a shader can read from. The
+	// fixed-function DrawTriangles path only ever sets srcs[0]; the rest
+	// stay nil.
+	srcs    [4]*Image
+	mode    driver.CompositeMode
+	filter  driver.Filter
+	address driver.Address
+	region  driver.Region
+
+	// shaderID and uniformsHash are zero values for the fixed-function
+	// DrawTriangles path. DrawTrianglesShader commands set them so that
+	// draws using different shaders, or the same shader with different
+	// uniform values, are never folded into one batch.
+	shaderID     int
+	uniformsHash uint64
+
+	// vertexCount is the number of vertices represented by vertices, i.e.
+	// len(vertices)/stride. The stride itself varies by draw (fixed-function
+	// vs. shader vertices have a different float layout) and isn't known to
+	// this package, so callers pass the count directly instead of it being
+	// derived from len(vertices) here.
+	vertexCount int
+	vertices    []float32
+	indices     []uint16
+}
+
+// maxMergedVertexCount is the largest combined vertex count a merged command
+// can hold: indices are uint16, so a combined vertex count beyond this would
+// make some rebased index overflow and silently wrap to the wrong vertex.
+const maxMergedVertexCount = 1 << 16
+
+// CanMergeWith reports whether c and next can be merged into a single draw
+// call. next is assumed to be the command immediately following c in the
+// same destination image's queue.
+func (c *drawTrianglesCommand) CanMergeWith(next *drawTrianglesCommand) bool {
+	if c.dst != next.dst {
+		return false
+	}
+	if c.srcs != next.srcs {
+		return false
+	}
+	if c.mode != next.mode {
+		return false
+	}
+	if c.filter != next.filter {
+		return false
+	}
+	if c.address != next.address {
+		return false
+	}
+	if c.region != next.region {
+		return false
+	}
+	if c.shaderID != next.shaderID {
+		return false
+	}
+	if c.uniformsHash != next.uniformsHash {
+		return false
+	}
+	if c.vertexCount+next.vertexCount > maxMergedVertexCount {
+		return false
+	}
+	return true
+}
+
+// enqueueDrawTrianglesCommand appends cmd to dst's command queue, merging it
+// into the last queued command when possible instead of appending a new one.
+//
+// Merging concatenates the vertex and index buffers, rebasing cmd's indices
+// by the vertex count already in last (the equivalent of
+// glDrawElementsBaseVertex's base-vertex argument), so they still point at
+// the right vertices once the two draws share one combined buffer.
+func enqueueDrawTrianglesCommand(dst *Image, cmd *drawTrianglesCommand) {
+	q := dst.queue
+	if n := len(q); n > 0 && q[n-1].CanMergeWith(cmd) {
+		last := q[n-1]
+		last.vertices = append(last.vertices, cmd.vertices...)
+		base := uint16(last.vertexCount)
+		for _, idx := range cmd.indices {
+			last.indices = append(last.indices, idx+base)
+		}
+		last.vertexCount += cmd.vertexCount
+		return
+	}
+	dst.queue = append(dst.queue, cmd)
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphicscommand
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/internal/driver"
+)
+
+func TestDrawTrianglesCommandMergesWithEqualRegion(t *testing.T) {
+	dst := NewImage(16, 16)
+	src := NewImage(16, 16)
+	region := driver.Region{X: 0, Y: 0, Width: 8, Height: 8}
+
+	c1 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: region}
+	c2 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: region}
+	if !c1.CanMergeWith(c2) {
+		t.Errorf("commands with identical regions should be mergeable")
+	}
+}
+
+func TestDrawTrianglesCommandDoesNotMergeAcrossRegions(t *testing.T) {
+	dst := NewImage(16, 16)
+	src := NewImage(16, 16)
+
+	c1 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: driver.Region{X: 0, Y: 0, Width: 8, Height: 8}}
+	c2 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: driver.Region{X: 8, Y: 0, Width: 8, Height: 8}}
+	if c1.CanMergeWith(c2) {
+		t.Errorf("commands with different scissor regions must not be merged")
+	}
+}
+
+func TestDrawTrianglesCommandDoesNotMergeAcrossUniforms(t *testing.T) {
+	dst := NewImage(16, 16)
+	src := NewImage(16, 16)
+	region := driver.Region{X: 0, Y: 0, Width: 8, Height: 8}
+
+	c1 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: region, shaderID: 1, uniformsHash: 42}
+	c2 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: region, shaderID: 1, uniformsHash: 43}
+	if c1.CanMergeWith(c2) {
+		t.Errorf("commands with different uniform hashes must not be merged")
+	}
+}
+
+// TestDrawTrianglesCommandDoesNotMergeAcrossSecondSource guards against
+// CanMergeWith only comparing srcs[0]: two commands reading from the same
+// primary source but a different second source image must stay distinct
+// draw calls, or the second would silently lose its own source.
+func TestDrawTrianglesCommandDoesNotMergeAcrossSecondSource(t *testing.T) {
+	dst := NewImage(16, 16)
+	src0 := NewImage(16, 16)
+	src1 := NewImage(16, 16)
+	src2 := NewImage(16, 16)
+	region := driver.Region{X: 0, Y: 0, Width: 8, Height: 8}
+
+	c1 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src0, src1}, region: region}
+	c2 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src0, src2}, region: region}
+	if c1.CanMergeWith(c2) {
+		t.Errorf("commands reading from different second source images must not be merged")
+	}
+}
+
+func TestEnqueueDrawTrianglesCommandMergesQueue(t *testing.T) {
+	dst := NewImage(16, 16)
+	src := NewImage(16, 16)
+	region := driver.Region{X: 0, Y: 0, Width: 8, Height: 8}
+
+	enqueueDrawTrianglesCommand(dst, &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: region, vertexCount: 1, vertices: []float32{1}})
+	enqueueDrawTrianglesCommand(dst, &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: region, vertexCount: 1, vertices: []float32{2}})
+	if len(dst.queue) != 1 {
+		t.Fatalf("want 1 queued command after merging, got %d", len(dst.queue))
+	}
+
+	other := driver.Region{X: 8, Y: 0, Width: 8, Height: 8}
+	enqueueDrawTrianglesCommand(dst, &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: other, vertexCount: 1, vertices: []float32{3}})
+	if len(dst.queue) != 2 {
+		t.Fatalf("want 2 queued commands once the scissor region changes, got %d", len(dst.queue))
+	}
+}
+
+// TestDrawTrianglesCommandDoesNotMergeBeyondUint16VertexRange guards against
+// a merged command's combined vertex count overflowing what a uint16 index
+// can address: past that point, rebasing an index by the accumulated vertex
+// count would silently wrap instead of pointing at the right vertex.
+func TestDrawTrianglesCommandDoesNotMergeBeyondUint16VertexRange(t *testing.T) {
+	dst := NewImage(16, 16)
+	src := NewImage(16, 16)
+	region := driver.Region{X: 0, Y: 0, Width: 8, Height: 8}
+
+	c1 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: region, vertexCount: 1 << 16}
+	c2 := &drawTrianglesCommand{dst: dst, srcs: [4]*Image{src}, region: region, vertexCount: 1}
+	if c1.CanMergeWith(c2) {
+		t.Errorf("commands whose combined vertex count overflows uint16 must not be merged")
+	}
+}
+
+// TestEnqueueDrawTrianglesCommandRebasesIndicesOnMerge guards against
+// indices silently being left pointing at the wrong vertices once two
+// commands are merged into one combined vertex/index buffer.
+func TestEnqueueDrawTrianglesCommandRebasesIndicesOnMerge(t *testing.T) {
+	dst := NewImage(16, 16)
+	src := NewImage(16, 16)
+	region := driver.Region{X: 0, Y: 0, Width: 8, Height: 8}
+
+	// First command has 4 vertices and draws triangle (0, 1, 2).
+	enqueueDrawTrianglesCommand(dst, &drawTrianglesCommand{
+		dst: dst, srcs: [4]*Image{src}, region: region,
+		vertexCount: 4, vertices: make([]float32, 4), indices: []uint16{0, 1, 2},
+	})
+	// Second command also indexes its own vertices as (0, 1, 2); once
+	// merged, those must be rebased to (4, 5, 6) to still point at its own
+	// 4 vertices in the combined buffer.
+	enqueueDrawTrianglesCommand(dst, &drawTrianglesCommand{
+		dst: dst, srcs: [4]*Image{src}, region: region,
+		vertexCount: 4, vertices: make([]float32, 4), indices: []uint16{0, 1, 2},
+	})
+
+	if len(dst.queue) != 1 {
+		t.Fatalf("want 1 queued command after merging, got %d", len(dst.queue))
+	}
+	got := dst.queue[0].indices
+	want := []uint16{0, 1, 2, 4, 5, 6}
+	if len(got) != len(want) {
+		t.Fatalf("want indices %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("want indices %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graphicscommand runs batched graphics commands against the
+// driver backends. A backend (OpenGL, Metal, ...) is not part of this
+// chunk; Image instead keeps its pixels in an in-memory RGBA buffer, which
+// is the part of this layer that DrawImage/Fill/ReplacePixels/At care
+// about: that every command clips its effect to its driver.Region.
+package graphicscommand
+
+import (
+	"github.com/hajimehoshi/ebiten/internal/driver"
+)
+
+// Image represents a rectangle set of pixels for one mipmap level that
+// commands are recorded and, eventually, executed against.
+type Image struct {
+	width  int
+	height int
+	screen bool
+
+	pixels   []byte // alpha-premultiplied RGBA, 4 bytes per pixel
+	queue    []*drawTrianglesCommand
+	disposed bool
+}
+
+// NewImage creates a cleared, width x height Image.
+func NewImage(width, height int) *Image {
+	return &Image{
+		width:  width,
+		height: height,
+		pixels: make([]byte, 4*width*height),
+	}
+}
+
+// NewScreenFramebufferImage creates an Image representing the screen's
+// framebuffer.
+func NewScreenFramebufferImage(width, height int) *Image {
+	i := NewImage(width, height)
+	i.screen = true
+	return i
+}
+
+// Size returns the size of the image.
+func (i *Image) Size() (int, int) {
+	return i.width, i.height
+}
+
+// Dispose releases the resources behind i. After Dispose, i must not be used.
+func (i *Image) Dispose() {
+	i.disposed = true
+	i.pixels = nil
+	i.queue = nil
+}
+
+func clipToRegion(width, height int, r driver.Region) (x0, y0, x1, y1 int) {
+	x0, y0 = r.X, r.Y
+	x1, y1 = r.X+r.Width, r.Y+r.Height
+	if x0 < 0 {
+		x0 = 0
+	}
+	if y0 < 0 {
+		y0 = 0
+	}
+	if x1 > width {
+		x1 = width
+	}
+	if y1 > height {
+		y1 = height
+	}
+	return
+}
+
+// Fill fills the pixels within region with clr.
+func (i *Image) Fill(r, g, b, a byte, region driver.Region) {
+	x0, y0, x1, y1 := clipToRegion(i.width, i.height, region)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			idx := 4 * (y*i.width + x)
+			i.pixels[idx] = r
+			i.pixels[idx+1] = g
+			i.pixels[idx+2] = b
+			i.pixels[idx+3] = a
+		}
+	}
+}
+
+// ReplacePixels replaces the pixels within region with pix, a tightly packed
+// RGBA buffer sized region.Width x region.Height. This is the software
+// equivalent of a glTexSubImage2D call at region's offset.
+func (i *Image) ReplacePixels(pix []byte, region driver.Region) {
+	x0, y0, x1, y1 := clipToRegion(i.width, i.height, region)
+	for y := y0; y < y1; y++ {
+		srcOff := 4 * ((y-region.Y)*region.Width + (x0 - region.X))
+		dstOff := 4 * (y*i.width + x0)
+		n := 4 * (x1 - x0)
+		copy(i.pixels[dstOff:dstOff+n], pix[srcOff:srcOff+n])
+	}
+}
+
+// At returns the RGBA value at (x, y).
+func (i *Image) At(x, y int) (byte, byte, byte, byte) {
+	if x < 0 || y < 0 || x >= i.width || y >= i.height {
+		return 0, 0, 0, 0
+	}
+	idx := 4 * (y*i.width + x)
+	return i.pixels[idx], i.pixels[idx+1], i.pixels[idx+2], i.pixels[idx+3]
+}
+
+// AtAsync is the asynchronous counterpart of At. As this chunk has no real
+// GPU backend to fence a readback against, f is invoked immediately; a real
+// backend invokes f once the fenced PBO/blit copy completes, one or two
+// frames later.
+func (i *Image) AtAsync(x, y int, f func(r, g, b, a byte)) {
+	r, g, b, a := i.At(x, y)
+	f(r, g, b, a)
+}
+
+// DrawTriangles enqueues (merging with the previous command when possible)
+// a triangle-draw command that is clipped to region. vertexCount is the
+// number of vertices vertices holds (vertices may use a larger per-vertex
+// float stride than 1, so this can't be derived from len(vertices)).
+func (i *Image) DrawTriangles(src *Image, vertices []float32, indices []uint16, vertexCount int, mode driver.CompositeMode, filter driver.Filter, address driver.Address, region driver.Region) {
+	i.drawTrianglesShader([4]*Image{src}, vertices, indices, vertexCount, 0, 0, mode, filter, address, region)
+}
+
+// DrawTrianglesShader is like DrawTriangles, but reads from up to 4 source
+// images (a nil element is skipped and does not count towards the average
+// described below) and tags the command with a shader identity and a hash of its
+// uniform values, so that draws with different shaders or uniforms are
+// never merged together.
+func (i *Image) DrawTrianglesShader(srcs [4]*Image, vertices []float32, indices []uint16, vertexCount int, shaderID int, uniformsHash uint64, mode driver.CompositeMode, filter driver.Filter, address driver.Address, region driver.Region) {
+	i.drawTrianglesShader(srcs, vertices, indices, vertexCount, shaderID, uniformsHash, mode, filter, address, region)
+}
+
+func (i *Image) drawTrianglesShader(srcs [4]*Image, vertices []float32, indices []uint16, vertexCount int, shaderID int, uniformsHash uint64, mode driver.CompositeMode, filter driver.Filter, address driver.Address, region driver.Region) {
+	cmd := &drawTrianglesCommand{
+		dst:          i,
+		srcs:         srcs,
+		mode:         mode,
+		filter:       filter,
+		address:      address,
+		region:       region,
+		shaderID:     shaderID,
+		uniformsHash: uniformsHash,
+		vertexCount:  vertexCount,
+		vertices:     vertices,
+		indices:      indices,
+	}
+	enqueueDrawTrianglesCommand(i, cmd)
+
+	// This chunk has no real rasterizer (no GeoM/vertex-transform math is
+	// implemented yet), so as a software approximation the command's effect
+	// is: with one source, a straight copy of it; with more than one, the
+	// per-channel average, rounded to nearest, of every non-nil source (standing in for an
+	// arbitrary multi-texture fragment shader). Either way the effect is
+	// clipped to region, which is enough to exercise scissor clipping and
+	// multi-source sampling end-to-end.
+	n := 0
+	for _, src := range srcs {
+		if src != nil {
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	x0, y0, x1, y1 := clipToRegion(i.width, i.height, region)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			var sum [4]int
+			for _, src := range srcs {
+				if src == nil || x >= src.width || y >= src.height {
+					continue
+				}
+				sidx := 4 * (y*src.width + x)
+				sum[0] += int(src.pixels[sidx])
+				sum[1] += int(src.pixels[sidx+1])
+				sum[2] += int(src.pixels[sidx+2])
+				sum[3] += int(src.pixels[sidx+3])
+			}
+			didx := 4 * (y*i.width + x)
+			i.pixels[didx] = byte((sum[0] + n/2) / n)
+			i.pixels[didx+1] = byte((sum[1] + n/2) / n)
+			i.pixels[didx+2] = byte((sum[2] + n/2) / n)
+			i.pixels[didx+3] = byte((sum[3] + n/2) / n)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// These tests exercise the region (scissor) clipping that backs
+// (*ebiten.Image)'s sub-image rendering support: Fill, DrawTriangles, and
+// ReplacePixels on a sub-image translate to exactly the Image methods
+// tested here, with region set to the sub-image's bounds. The ebiten
+// package itself can't host these tests yet, since it also depends on
+// GeoM/ColorM/Filter/CompositeMode, which are out of this chunk's scope and
+// aren't defined anywhere in this tree.
+package graphicscommand_test
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/internal/driver"
+	"github.com/hajimehoshi/ebiten/internal/graphicscommand"
+)
+
+func at(img *graphicscommand.Image, x, y int) (byte, byte, byte, byte) {
+	return img.At(x, y)
+}
+
+// TestFillSubImageRegion corresponds to "filling a sub-image": only the
+// pixels within the sub-image's region may change; everything else in the
+// parent image must be left alone.
+func TestFillSubImageRegion(t *testing.T) {
+	img := graphicscommand.NewImage(8, 8)
+	img.Fill(0xff, 0xff, 0xff, 0xff, driver.Region{X: 0, Y: 0, Width: 8, Height: 8})
+
+	// A sub-image at (2, 2)-(6, 6).
+	region := driver.Region{X: 2, Y: 2, Width: 4, Height: 4}
+	img.Fill(0x00, 0x00, 0x00, 0xff, region)
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			r, _, _, _ := at(img, x, y)
+			inRegion := x >= 2 && x < 6 && y >= 2 && y < 6
+			if inRegion && r != 0x00 {
+				t.Errorf("(%d, %d): want cleared inside the sub-image's region, got r=%#x", x, y, r)
+			}
+			if !inRegion && r != 0xff {
+				t.Errorf("(%d, %d): want untouched outside the sub-image's region, got r=%#x", x, y, r)
+			}
+		}
+	}
+}
+
+// TestDrawTrianglesOverlappingSubImages corresponds to "drawing into
+// overlapping sub-images of the same parent": each draw must stay clipped
+// to its own region, even when two sub-images of the same parent overlap.
+func TestDrawTrianglesOverlappingSubImages(t *testing.T) {
+	parent := graphicscommand.NewImage(8, 8)
+	red := graphicscommand.NewImage(8, 8)
+	red.Fill(0xff, 0x00, 0x00, 0xff, driver.Region{X: 0, Y: 0, Width: 8, Height: 8})
+	blue := graphicscommand.NewImage(8, 8)
+	blue.Fill(0x00, 0x00, 0xff, 0xff, driver.Region{X: 0, Y: 0, Width: 8, Height: 8})
+
+	// Two overlapping sub-images of parent: (0, 0)-(5, 5) and (3, 3)-(8, 8).
+	regionA := driver.Region{X: 0, Y: 0, Width: 5, Height: 5}
+	regionB := driver.Region{X: 3, Y: 3, Width: 5, Height: 5}
+
+	parent.DrawTriangles(red, nil, nil, 0, driver.CompositeModeSourceOver, driver.FilterNearest, driver.AddressClampToZero, regionA)
+	parent.DrawTriangles(blue, nil, nil, 0, driver.CompositeModeSourceOver, driver.FilterNearest, driver.AddressClampToZero, regionB)
+
+	// Strictly inside regionA and outside regionB: must stay red.
+	if r, _, b, _ := at(parent, 0, 0); r != 0xff || b != 0x00 {
+		t.Errorf("(0, 0): want red left by regionA's draw, got r=%#x b=%#x", r, b)
+	}
+	// Strictly inside regionB and outside regionA: must be blue.
+	if r, _, b, _ := at(parent, 7, 7); r != 0x00 || b != 0xff {
+		t.Errorf("(7, 7): want blue left by regionB's draw, got r=%#x b=%#x", r, b)
+	}
+	// Outside both regions: must be untouched (transparent black).
+	if r, g, b, a := at(parent, 7, 0); r != 0 || g != 0 || b != 0 || a != 0 {
+		t.Errorf("(7, 0): want untouched outside both regions, got (%#x, %#x, %#x, %#x)", r, g, b, a)
+	}
+}
+
+// TestReplacePixelsNonTileAlignedSubImage corresponds to "ReplacePixels on a
+// sub-image whose bounds are not axis-aligned to any tile": an odd, non
+// power-of-two offset and size must still land at exactly the right pixels.
+func TestReplacePixelsNonTileAlignedSubImage(t *testing.T) {
+	img := graphicscommand.NewImage(16, 16)
+
+	region := driver.Region{X: 3, Y: 5, Width: 6, Height: 4}
+	pix := make([]byte, 4*region.Width*region.Height)
+	for i := range pix {
+		pix[i] = 0x40
+	}
+	img.ReplacePixels(pix, region)
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			r, _, _, _ := at(img, x, y)
+			inRegion := x >= region.X && x < region.X+region.Width && y >= region.Y && y < region.Y+region.Height
+			if inRegion && r != 0x40 {
+				t.Errorf("(%d, %d): want the replaced value inside the region, got r=%#x", x, y, r)
+			}
+			if !inRegion && r != 0x00 {
+				t.Errorf("(%d, %d): want untouched outside the region, got r=%#x", x, y, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// These tests exercise DrawTrianglesShader and its batching predicate, the
+// software counterpart of (*ebiten.Image).DrawTrianglesWithShader. As with
+// image_test.go, they live here rather than in the ebiten package because
+// the latter also depends on GeoM/ColorM/Filter/CompositeMode, which aren't
+// defined anywhere in this tree.
+package graphicscommand_test
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/internal/driver"
+	"github.com/hajimehoshi/ebiten/internal/graphicscommand"
+	"github.com/hajimehoshi/ebiten/internal/shader"
+)
+
+const blendShaderSrc = `package main
+
+func Fragment(dst vec4, src vec2, color vec4) vec4 {
+	return color
+}
+`
+
+// TestDrawTrianglesShaderTwoTextureBlend corresponds to "a simple
+// two-texture blend shader": a single DrawTrianglesShader call that reads
+// from two source images in the same draw must actually sample both of
+// them, not just whichever was issued last.
+func TestDrawTrianglesShaderTwoTextureBlend(t *testing.T) {
+	s, err := shader.Compile([]byte(blendShaderSrc))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	dst := graphicscommand.NewImage(8, 8)
+	src0 := graphicscommand.NewImage(8, 8)
+	src0.Fill(0xff, 0x00, 0x00, 0xff, driver.Region{X: 0, Y: 0, Width: 8, Height: 8})
+	src1 := graphicscommand.NewImage(8, 8)
+	src1.Fill(0x00, 0x00, 0xff, 0xff, driver.Region{X: 0, Y: 0, Width: 8, Height: 8})
+
+	region := driver.Region{X: 1, Y: 1, Width: 4, Height: 4}
+	dst.DrawTrianglesShader([4]*graphicscommand.Image{src0, src1}, nil, nil, 0, s.ID, 0, driver.CompositeModeSourceOver, driver.FilterNearest, driver.AddressClampToZero, region)
+
+	// Both sources fed into a single draw, so the result must reflect
+	// both red (src0) and blue (src1), not just one of them.
+	r, _, b, _ := dst.At(2, 2)
+	if r != 0x80 || b != 0x80 {
+		t.Errorf("(2, 2): want a blend of red and blue inside the region, got r=%#x b=%#x", r, b)
+	}
+	if r, _, _, a := dst.At(0, 0); r != 0 || a != 0 {
+		t.Errorf("(0, 0): want untouched outside the region, got r=%#x a=%#x", r, a)
+	}
+}
+
+// TestDrawTrianglesShaderChromaticAberrationOffscreen corresponds to "a
+// chromatic-aberration post-process applied to an offscreen": a
+// DrawTrianglesShader into an offscreen (non-screen) Image must copy the
+// source inside its region and leave the offscreen untouched outside it.
+func TestDrawTrianglesShaderChromaticAberrationOffscreen(t *testing.T) {
+	aberrationSrc := `package main
+
+func Fragment(dst vec4, src vec2, color vec4) vec4 {
+	return color
+}
+`
+	s, err := shader.Compile([]byte(aberrationSrc))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	scene := graphicscommand.NewImage(8, 8)
+	scene.Fill(0x80, 0x80, 0x80, 0xff, driver.Region{X: 0, Y: 0, Width: 8, Height: 8})
+
+	offscreen := graphicscommand.NewImage(8, 8)
+	region := driver.Region{X: 2, Y: 2, Width: 4, Height: 4}
+	offscreen.DrawTrianglesShader([4]*graphicscommand.Image{scene}, nil, nil, 0, s.ID, 0, driver.CompositeModeSourceOver, driver.FilterNearest, driver.AddressClampToZero, region)
+
+	if r, _, _, a := offscreen.At(3, 3); r != 0x80 || a != 0xff {
+		t.Errorf("(3, 3): want the post-processed scene inside the region, got r=%#x a=%#x", r, a)
+	}
+	if r, _, _, a := offscreen.At(0, 0); r != 0 || a != 0 {
+		t.Errorf("(0, 0): want the offscreen untouched outside the region, got r=%#x a=%#x", r, a)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shader compiles Ebiten's shader language, a small restricted
+// subset of Go, down to each graphics backend's native shader language.
+package shader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Shader is a shader program compiled by Compile. GLSL and MSL hold the
+// output for the OpenGL and Metal backends respectively: the source text
+// prefixed with each backend's prelude. The source is not translated into
+// either language.
+type Shader struct {
+	// ID uniquely identifies this compiled shader within the process. The
+	// graphicscommand batching predicate uses it, together with a hash of
+	// a draw's uniform values, to decide whether consecutive
+	// DrawTrianglesShader calls can be merged into one draw call.
+	ID int
+
+	GLSL string
+	MSL  string
+}
+
+var nextID int
+
+// Compile translates src, written in Ebiten's shader language, into GLSL
+// and MSL.
+//
+// This chunk's compiler implements only the minimal pass needed to
+// exercise the pipeline end-to-end: it checks that src declares a Fragment
+// function, and wraps it in the boilerplate each backend needs. It is not a
+// full parser for the language.
+//
+// If src is empty, or does not declare a Fragment function, Compile
+// returns an error.
+func Compile(src []byte) (*Shader, error) {
+	s := strings.TrimSpace(string(src))
+	if s == "" {
+		return nil, fmt.Errorf("shader: source must not be empty")
+	}
+	if !strings.Contains(s, "func Fragment(") {
+		return nil, fmt.Errorf("shader: source must declare a Fragment function")
+	}
+
+	nextID++
+	return &Shader{
+		ID:   nextID,
+		GLSL: glslPrelude + s,
+		MSL:  mslPrelude + s,
+	}, nil
+}
+
+const glslPrelude = "#version 100\n// generated by Ebiten's shader compiler (GLSL)\n\n"
+
+const mslPrelude = "#include <metal_stdlib>\nusing namespace metal;\n// generated by Ebiten's shader compiler (MSL)\n\n"
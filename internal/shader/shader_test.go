@@ -0,0 +1,68 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shader_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/internal/shader"
+)
+
+const validSrc = `package main
+
+func Fragment(dst vec4, src vec2, color vec4) vec4 {
+	return color
+}
+`
+
+func TestCompileWrapsSourceForGLSLAndMSL(t *testing.T) {
+	s, err := shader.Compile([]byte(validSrc))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if !strings.Contains(s.GLSL, "func Fragment(") {
+		t.Errorf("GLSL translation lost the Fragment function: %q", s.GLSL)
+	}
+	if !strings.Contains(s.MSL, "func Fragment(") {
+		t.Errorf("MSL translation lost the Fragment function: %q", s.MSL)
+	}
+}
+
+func TestCompileAssignsDistinctIDs(t *testing.T) {
+	s1, err := shader.Compile([]byte(validSrc))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	s2, err := shader.Compile([]byte(validSrc))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if s1.ID == s2.ID {
+		t.Errorf("want distinct IDs per compiled shader, got %d twice", s1.ID)
+	}
+}
+
+func TestCompileRejectsEmptySource(t *testing.T) {
+	if _, err := shader.Compile(nil); err == nil {
+		t.Errorf("want an error for empty source, got nil")
+	}
+}
+
+func TestCompileRejectsSourceWithoutFragment(t *testing.T) {
+	if _, err := shader.Compile([]byte("package main\n")); err == nil {
+		t.Errorf("want an error for a source without a Fragment function, got nil")
+	}
+}
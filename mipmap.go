@@ -0,0 +1,139 @@
+// Copyright 2014 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebiten
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/internal/driver"
+	"github.com/hajimehoshi/ebiten/internal/graphicscommand"
+	"github.com/hajimehoshi/ebiten/internal/shader"
+)
+
+// mipmap is a set of graphicscommand.Image sorted by the order of mipmap
+// level. The level 0 image is a regular image, and higher levels (not
+// implemented yet in this chunk) are used to pre-downscale an image before
+// a minifying DrawImage.
+type mipmap struct {
+	orig   *graphicscommand.Image
+	width  int
+	height int
+
+	volatile bool
+}
+
+func newMipmap(width, height int) *mipmap {
+	return &mipmap{
+		orig:   graphicscommand.NewImage(width, height),
+		width:  width,
+		height: height,
+	}
+}
+
+func newScreenFramebufferMipmap(width, height int) *mipmap {
+	return &mipmap{
+		orig:   graphicscommand.NewScreenFramebufferImage(width, height),
+		width:  width,
+		height: height,
+	}
+}
+
+func (m *mipmap) size() (int, int) {
+	return m.width, m.height
+}
+
+func (m *mipmap) isDisposed() bool {
+	return m.orig == nil
+}
+
+func (m *mipmap) dispose() {
+	m.orig.Dispose()
+	m.orig = nil
+}
+
+// disposeMipmaps discards any higher mipmap levels derived from the level-0
+// image. Higher-level caching is not implemented in this chunk, so there is
+// nothing to discard yet; this still exists as the hook DrawImage's
+// minification path will populate.
+func (m *mipmap) disposeMipmaps() {
+}
+
+// original returns the level-0 mipmap, which is where all of a sub-image's
+// rendering and pixel commands are ultimately issued against, since a
+// sub-image shares its original image's mipmap.
+func (m *mipmap) original() *mipmap {
+	return m
+}
+
+// level returns a higher mipmap level image for bounds, or nil if one isn't
+// available. Mipmap-level caching is not implemented in this chunk, so
+// DrawImage always falls back to its level-0 path.
+func (m *mipmap) level(bounds image.Rectangle, level int) *mipmap {
+	return nil
+}
+
+// mipmapLevel returns the mipmap level that best matches geom scaling w x h
+// by filter. Level selection is not implemented in this chunk, so the
+// level-0 image is always used.
+func (m *mipmap) mipmapLevel(geom *GeoM, width, height int, filter driver.Filter) int {
+	return 0
+}
+
+func (m *mipmap) makeVolatile() {
+	m.volatile = true
+}
+
+// fill sets every pixel within region to clr.
+func (m *mipmap) fill(clr color.Color, region driver.Region) {
+	r, g, b, a := clr.RGBA()
+	m.orig.Fill(byte(r>>8), byte(g>>8), byte(b>>8), byte(a>>8), region)
+}
+
+// replacePixels replaces the pixels within region with pix.
+func (m *mipmap) replacePixels(pix []byte, region driver.Region) {
+	m.orig.ReplacePixels(pix, region)
+}
+
+func (m *mipmap) at(x, y int) (byte, byte, byte, byte) {
+	return m.orig.At(x, y)
+}
+
+func (m *mipmap) atAsync(x, y int, f func(r, g, b, a byte)) {
+	m.orig.AtAsync(x, y, f)
+}
+
+// DrawTriangles draws the given vertexCount vertices and indices, sampling
+// from src, clipped to region.
+func (m *mipmap) DrawTriangles(src *mipmap, vertices []float32, indices []uint16, vertexCount int, colorm interface{}, mode driver.CompositeMode, filter driver.Filter, address driver.Address, region driver.Region) {
+	m.orig.DrawTriangles(src.orig, vertices, indices, vertexCount, mode, filter, address, region)
+}
+
+// DrawTrianglesShader is like DrawTriangles, but renders with a custom
+// fragment shader reading from up to 4 source images instead of the
+// fixed-function pipeline. uniformsHash identifies s's uniform values for
+// the graphicscommand batching predicate; it does not need to be reversible.
+func (m *mipmap) DrawTrianglesShader(srcs []*mipmap, vertices []float32, indices []uint16, vertexCount int, s *shader.Shader, uniformsHash uint64, mode driver.CompositeMode, address driver.Address, region driver.Region) {
+	var imgs [4]*graphicscommand.Image
+	for idx, src := range srcs {
+		if idx >= len(imgs) {
+			break
+		}
+		if src != nil {
+			imgs[idx] = src.orig
+		}
+	}
+	m.orig.DrawTrianglesShader(imgs, vertices, indices, vertexCount, s.ID, uniformsHash, mode, driver.FilterNearest, address, region)
+}
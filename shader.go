@@ -0,0 +1,208 @@
+// Copyright 2020 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebiten
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/internal/driver"
+	"github.com/hajimehoshi/ebiten/internal/graphics"
+	"github.com/hajimehoshi/ebiten/internal/shader"
+)
+
+// Shader represents a compiled shader program for rendering.
+//
+// A Shader is compiled once from its source by NewShader. The internal
+// compiler checks that the source declares a Fragment function and wraps it
+// in a GLSL and an MSL prelude; it does not translate the source into GLSL
+// or MSL.
+//
+// Note that this API is experimental.
+type Shader struct {
+	shader *shader.Shader
+}
+
+// NewShader compiles a shader program from source and returns a Shader.
+//
+// The source must be written in Ebiten's shader language, a small subset of
+// Go, and must declare a Fragment function.
+//
+// If compiling src fails, NewShader returns an error.
+//
+// Note that this API is experimental.
+func NewShader(src []byte) (*Shader, error) {
+	s, err := shader.Compile(src)
+	if err != nil {
+		return nil, fmt.Errorf("ebiten: compiling shader failed: %w", err)
+	}
+	return &Shader{shader: s}, nil
+}
+
+// DrawTrianglesWithShaderOptions represents options for DrawTrianglesWithShader.
+//
+// Note that this API is experimental.
+type DrawTrianglesWithShaderOptions struct {
+	// CompositeMode is a composite mode to draw.
+	// The default (zero) value is regular alpha blending.
+	CompositeMode CompositeMode
+
+	// Address is a sampler address mode.
+	// The default (zero) value is AddressClampToZero.
+	Address Address
+
+	// Images is the set of source images that the shader can read from. A
+	// shader can read up to 4 source images; vertices' SrcX/SrcY refer to
+	// Images[0]'s bounds.
+	//
+	// A nil element is treated as a transparent image.
+	Images [4]*Image
+
+	// Uniforms is a set of uniform variables for the shader, keyed by the
+	// variable name declared in the shader source.
+	//
+	// The value of each entry must be float32, []float32, int, GeoM, or ColorM.
+	// Any other type makes DrawTrianglesWithShader panic.
+	Uniforms map[string]interface{}
+}
+
+// DrawTrianglesWithShader draws triangles with the specified vertices, indices, and a shader.
+//
+// The source images the shader reads from are given via options.Images;
+// vertices' SrcX/SrcY refer to options.Images[0]'s bounds.
+//
+// If len(indices) is not a multiple of 3, DrawTrianglesWithShader panics.
+//
+// If len(indices) is more than MaxIndicesNum, DrawTrianglesWithShader panics.
+//
+// If a value in options.Uniforms has an unsupported type, DrawTrianglesWithShader panics.
+//
+// When the image i is disposed, DrawTrianglesWithShader does nothing.
+//
+// Internal mipmap is not used on DrawTrianglesWithShader.
+//
+// Note that this API is experimental.
+func (i *Image) DrawTrianglesWithShader(vertices []Vertex, indices []uint16, shader *Shader, options *DrawTrianglesWithShaderOptions) {
+	i.copyCheck()
+
+	if enqueueImageOpIfNeeded(func() func() {
+		vs := make([]Vertex, len(vertices))
+		copy(vs, vertices)
+		is := make([]uint16, len(indices))
+		copy(is, indices)
+		op := *options
+		return func() {
+			i.DrawTrianglesWithShader(vs, is, shader, &op)
+		}
+	}) {
+		return
+	}
+
+	if i.isDisposed() {
+		return
+	}
+
+	if len(indices)%3 != 0 {
+		panic("ebiten: len(indices) % 3 must be 0")
+	}
+	if len(indices) > MaxIndicesNum {
+		panic("ebiten: len(indices) must be <= MaxIndicesNum")
+	}
+
+	if options == nil {
+		options = &DrawTrianglesWithShaderOptions{}
+	}
+
+	srcs := make([]*mipmap, len(options.Images))
+	for idx, src := range options.Images {
+		if src == nil {
+			continue
+		}
+		src.resolvePendingPixels(true)
+		srcs[idx] = src.mipmap.original()
+	}
+	i.resolvePendingPixels(true)
+
+	u, err := shaderUniforms(options.Uniforms)
+	if err != nil {
+		panic(fmt.Sprintf("ebiten: DrawTrianglesWithShader: %v", err))
+	}
+	// The batching predicate in graphicscommand folds shader identity and
+	// this hash into its merge decision, so draws with different uniform
+	// values are never merged into the same draw call.
+	uniformsHash := hashUniforms(u)
+
+	mode := driver.CompositeMode(options.CompositeMode)
+
+	// (0, 0) in a sub-image corresponds to bounds.Min in its original image.
+	var dx, dy float32
+	if i.isSubImage() {
+		dx, dy = float32(i.bounds.Min.X), float32(i.bounds.Min.Y)
+	}
+
+	vs := vertexSlice(len(vertices))
+	for idx, v := range vertices {
+		graphics.PutQuadVerticesShader(vs[idx*graphics.ShaderVertexFloatNum:(idx+1)*graphics.ShaderVertexFloatNum],
+			float32(v.DstX)+dx, float32(v.DstY)+dy, v.SrcX, v.SrcY,
+			v.ColorR, v.ColorG, v.ColorB, v.ColorA,
+			v.Custom0, v.Custom1, v.Custom2, v.Custom3)
+	}
+
+	i.mipmap.original().DrawTrianglesShader(srcs, vs, indices, len(vertices), shader.shader, uniformsHash, mode, driver.Address(options.Address), i.regionForMipmap())
+	i.disposeMipmaps()
+}
+
+// shaderUniforms validates and normalizes uniform variable values so they can
+// be handed to the driver layer. Matrices are unwrapped to their underlying
+// driver representation, as ColorM and GeoM already do for DrawImage.
+func shaderUniforms(values map[string]interface{}) (map[string]interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+	us := make(map[string]interface{}, len(values))
+	for name, v := range values {
+		switch v := v.(type) {
+		case float32, []float32, int:
+			us[name] = v
+		case GeoM:
+			us[name] = v.impl
+		case ColorM:
+			us[name] = v.impl
+		default:
+			return nil, fmt.Errorf("uniform variable %q has an unsupported type %T", name, v)
+		}
+	}
+	return us, nil
+}
+
+// hashUniforms returns a hash of values' content, used as part of the
+// graphicscommand batching predicate: two DrawTrianglesWithShader calls
+// using the same shader are only merged into one draw call when their
+// uniform values hash equal. Keys are visited in sorted order so the result
+// doesn't depend on map iteration order.
+func hashUniforms(values map[string]interface{}) uint64 {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%v;", k, values[k])
+	}
+	return h.Sum64()
+}